@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceLoc records where a parsed command came from, so the emitter can
+// print it back as a comment and the linker/optimizer can carry it through
+// fusion and dead code elimination.
+type SourceLoc struct {
+	File string
+	Line int
+	Text string
+}
+
+// MapEntry maps a contiguous ROM address range back to the VM source
+// location that produced it, written out as the program's .map file.
+type MapEntry struct {
+	StartAddress int    `json:"startAddress"`
+	EndAddress   int    `json:"endAddress"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+}
+
+// annotateMode selects how much source-mapping detail is emitted as
+// comments above and alongside the generated assembly.
+type annotateMode int
+
+const (
+	annotateOff annotateMode = iota
+	annotateLine
+	annotateFull
+)
+
+func (m annotateMode) String() string {
+	switch m {
+	case annotateLine:
+		return "line"
+	case annotateFull:
+		return "full"
+	default:
+		return "off"
+	}
+}
+
+func (m *annotateMode) Set(s string) error {
+	switch s {
+	case "", "off":
+		*m = annotateOff
+	case "line":
+		*m = annotateLine
+	case "full":
+		*m = annotateFull
+	default:
+		return fmt.Errorf("invalid -annotate value %q (want off, line, or full)", s)
+	}
+	return nil
+}
+
+// describeInstruction gives a short, generic description of a single Hack
+// instruction's role, used in -annotate=full mode. It recognizes the
+// handful of idioms codegen.go actually emits rather than attempting to
+// fully decompile arbitrary assembly.
+func describeInstruction(line string) string {
+	switch {
+	case line == "":
+		return ""
+	case strings.HasPrefix(line, "("):
+		return ""
+	case line == "AM=M-1":
+		return "pop the stack, addressing the new top"
+	case line == "A=M-1":
+		return "address the current top of the stack"
+	case line == "A=M":
+		return "address the top of the stack"
+	case line == "M=M+1":
+		return "advance the stack pointer"
+	case line == "D=A":
+		return "load a constant into D"
+	case strings.HasPrefix(line, "D=M"):
+		return "load a value into D"
+	case strings.HasPrefix(line, "M=D+"):
+		return "add"
+	case strings.HasPrefix(line, "M=M-D") || strings.HasPrefix(line, "M=D-"):
+		return "subtract"
+	case strings.HasPrefix(line, "M=D&"):
+		return "bitwise and"
+	case strings.HasPrefix(line, "M=D|"):
+		return "bitwise or"
+	case line == "M=-M":
+		return "negate"
+	case line == "M=!M":
+		return "bitwise not"
+	case strings.HasPrefix(line, "M=D"):
+		return "store D"
+	case line == "0;JMP":
+		return "unconditional jump"
+	case strings.Contains(line, ";J"):
+		return "conditional jump"
+	case strings.HasPrefix(line, "@"):
+		return "address " + line[1:]
+	default:
+		return ""
+	}
+}
+
+// mergeSourceLocs collapses the source locations consumed by a peephole
+// fusion into one, so the fused command still annotates back to real VM
+// source rather than losing its mapping.
+func mergeSourceLocs(locs []SourceLoc) SourceLoc {
+	if len(locs) == 1 {
+		return locs[0]
+	}
+	texts := make([]string, len(locs))
+	for i, l := range locs {
+		texts[i] = l.Text
+	}
+	return SourceLoc{File: locs[0].File, Line: locs[0].Line, Text: strings.Join(texts, "; ")}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+// bootstrapMode selects how the program's startup preamble is emitted.
+type bootstrapMode int
+
+const (
+	bootstrapAuto bootstrapMode = iota
+	bootstrapFull
+	bootstrapNone
+)
+
+func (m bootstrapMode) String() string {
+	switch m {
+	case bootstrapFull:
+		return "full"
+	case bootstrapNone:
+		return "none"
+	default:
+		return "auto"
+	}
+}
+
+func (m *bootstrapMode) Set(s string) error {
+	switch s {
+	case "", "auto":
+		*m = bootstrapAuto
+	case "full":
+		*m = bootstrapFull
+	case "none":
+		*m = bootstrapNone
+	default:
+		return fmt.Errorf("invalid -bootstrap value %q (want auto, full, or none)", s)
+	}
+	return nil
+}
+
+// Bootstrap is the synthetic preamble commands placed ahead of a program's
+// translated functions.
+type Bootstrap struct {
+	Commands []Cmd
+	Sources  []SourceLoc
+}
+
+// BuildBootstrap decides what preamble to emit for mode, given whether the
+// parsed program defines Sys.init:
+//
+//   - auto: call Sys.init if it's defined, otherwise emit nothing, so a
+//     single-file chapter test that sets SP itself via its own .tst script
+//     isn't broken by a jump to a function that doesn't exist.
+//   - full: always initialize SP and call Sys.init, regardless of whether
+//     it's defined.
+//   - none: emit nothing; the caller is responsible for SP.
+//
+// The call is expressed as the same CallCmd the parser produces for a
+// user-written "call Sys.init 0", so it is emitted through translateCallCommand
+// exactly like any other call and its ret.0 label and frame layout match.
+func BuildBootstrap(mode bootstrapMode, sysInitDefined bool) Bootstrap {
+	callSysInit := mode == bootstrapFull || (mode == bootstrapAuto && sysInitDefined)
+	if !callSysInit {
+		return Bootstrap{}
+	}
+	return Bootstrap{
+		Commands: []Cmd{CallCmd{Name: "Sys.init", NumArgs: 0}},
+		Sources:  []SourceLoc{{File: "<bootstrap>", Text: "call Sys.init 0"}},
+	}
+}
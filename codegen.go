@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func translateReturnCommand() string {
+	// Assembly code to implement the VM return command.
+	return "@LCL\nD=M\n@5\nA=D-A\nD=M\n@R13\nM=D\n" +
+		"@SP\nA=M-1\nD=M\n@ARG\nA=M\nM=D\n" +
+		"D=A+1\n@SP\nM=D\n" +
+		"@LCL\nAM=M-1\nD=M\n@THAT\nM=D\n" +
+		"@LCL\nAM=M-1\nD=M\n@THIS\nM=D\n" +
+		"@LCL\nAM=M-1\nD=M\n@ARG\nM=D\n" +
+		"@LCL\nA=M-1\nD=M\n@LCL\nM=D\n" +
+		"@R13\nA=M\n0;JMP\n"
+
+}
+
+// translateGotoCommand scopes label to the function currently being
+// translated, exactly like translateLabelCommand, so the jump target
+// matches the label it's meant to reach.
+func (t *Translator) translateGotoCommand(label string) string {
+	// Example: goto IF_FALSE
+	// @Foo.bar$IF_FALSE
+	// 0;JMP
+	return fmt.Sprintf(
+		"@%s$%s\n"+
+			"0;JMP\n", t.currentFunction, label)
+}
+
+func (t *Translator) translateIfGotoCommand(label string) string {
+	// Example: if-goto IF_TRUE
+	// @SP
+	// AM=M-1
+	// D=M
+	// @Foo.bar$IF_TRUE
+	// D;JNE
+	return fmt.Sprintf(
+		"@SP\n"+
+			"AM=M-1\n"+
+			"D=M\n"+
+			"@%s$%s\n"+
+			"D;JNE\n", t.currentFunction, label)
+}
+
+// translateLabelCommand scopes label to the function currently being
+// translated, to ensure uniqueness across the program.
+func (t *Translator) translateLabelCommand(label string) string {
+	return fmt.Sprintf("(%s$%s)\n", t.currentFunction, label)
+}
+
+// translateCallCommand implements the VM call command: it pushes the
+// return address and the caller's state onto the stack, then repositions
+// ARG and LCL for the callee. A unique return label is generated using the
+// callee's name and this Translator's call counter.
+func (t *Translator) translateCallCommand(functionName string, numArgs int) (string, error) {
+	returnLabel := fmt.Sprintf("%s$ret.%d", functionName, t.callCounter)
+	t.callCounter++
+
+	// Push the return address onto the stack.
+	asm := fmt.Sprintf(
+		"@%s\n"+
+			"D=A\n"+
+			"@SP\n"+
+			"A=M\n"+
+			"M=D\n"+
+			"@SP\n"+
+			"M=M+1\n", returnLabel)
+
+	// Push LCL, ARG, THIS, and THAT onto the stack.
+	for _, segment := range []string{"LCL", "ARG", "THIS", "THAT"} {
+		asm += fmt.Sprintf(
+			"@%s\n"+
+				"D=M\n"+
+				"@SP\n"+
+				"A=M\n"+
+				"M=D\n"+
+				"@SP\n"+
+				"M=M+1\n", segment)
+	}
+
+	// Reposition ARG (ARG = SP - numArgs - 5).
+	asm += fmt.Sprintf(
+		"@SP\n"+
+			"D=M\n"+
+			"@%d\n"+
+			"D=D-A\n"+
+			"@5\n"+
+			"D=D-A\n"+
+			"@ARG\n"+
+			"M=D\n", numArgs)
+
+	// Reposition LCL (LCL = SP).
+	asm += "@SP\nD=M\n@LCL\nM=D\n"
+
+	// Transfer control to the called function.
+	asm += fmt.Sprintf("@%s\n0;JMP\n", functionName)
+
+	// Declare a label for the return address.
+	asm += fmt.Sprintf("(%s)\n", returnLabel)
+
+	return asm, nil
+}
+
+func translateFunctionCommand(functionName string, numLocals int) (string, error) {
+	var asm strings.Builder
+
+	// Label for the function entry.
+	asm.WriteString(fmt.Sprintf("(%s)\n", functionName))
+
+	// Initialize local variables to 0.
+	for i := 0; i < numLocals; i++ {
+		asm.WriteString("@SP\nA=M\nM=0\n") // Set the value at the top of the stack to 0.
+		asm.WriteString("@SP\nM=M+1\n")    // Increment the stack pointer.
+	}
+
+	return asm.String(), nil
+}
+
+// loadSegmentIntoD loads the value addressed by seg/index into D, leaving A
+// pointed at that address.
+func loadSegmentIntoD(seg Segment, index int, fileName string) (string, error) {
+	switch seg {
+	case SegConstant:
+		return fmt.Sprintf("@%d\nD=A\n", index), nil
+	case SegLocal:
+		return fmt.Sprintf("@LCL\nD=M\n@%d\nA=D+A\nD=M\n", index), nil
+	case SegArgument:
+		return fmt.Sprintf("@ARG\nD=M\n@%d\nA=D+A\nD=M\n", index), nil
+	case SegThis:
+		return fmt.Sprintf("@THIS\nD=M\n@%d\nA=D+A\nD=M\n", index), nil
+	case SegThat:
+		return fmt.Sprintf("@THAT\nD=M\n@%d\nA=D+A\nD=M\n", index), nil
+	case SegStatic:
+		return fmt.Sprintf("@%s.%d\nD=M\n", fileName, index), nil
+	case SegTemp:
+		return fmt.Sprintf("@R%d\nD=M\n", 5+index), nil
+	case SegPointer:
+		switch index {
+		case 0:
+			return "@THIS\nD=M\n", nil
+		case 1:
+			return "@THAT\nD=M\n", nil
+		default:
+			return "", fmt.Errorf("invalid index for pointer segment: %d", index)
+		}
+	default:
+		return "", fmt.Errorf("unsupported segment")
+	}
+}
+
+func segmentBase(seg Segment) string {
+	switch seg {
+	case SegLocal:
+		return "LCL"
+	case SegArgument:
+		return "ARG"
+	case SegThis:
+		return "THIS"
+	case SegThat:
+		return "THAT"
+	}
+	return ""
+}
+
+func (t *Translator) translatePushCommand(seg Segment, index int) (string, error) {
+	load, err := loadSegmentIntoD(seg, index, t.currentFile)
+	if err != nil {
+		return "", err
+	}
+	return load + "@SP\nA=M\nM=D\n@SP\nM=M+1\n", nil
+}
+
+func translateArithmeticCommand(op ArithOp) string {
+	switch op {
+	case OpAdd:
+		return "@SP\nAM=M-1\nD=M\nA=A-1\nM=D+M\n"
+	case OpSub:
+		return "@SP\nAM=M-1\nD=M\nA=A-1\nM=M-D\n"
+	case OpNeg:
+		return "@SP\nA=M-1\nM=-M\n"
+	case OpAnd:
+		return "@SP\nAM=M-1\nD=M\nA=A-1\nM=D&M\n"
+	case OpOr:
+		return "@SP\nAM=M-1\nD=M\nA=A-1\nM=D|M\n"
+	case OpNot:
+		return "@SP\nA=M-1\nM=!M\n"
+	}
+	return ""
+}
+
+func comparisonName(op ArithOp) (name, jump string) {
+	switch op {
+	case OpEq:
+		return "EQ", "JEQ"
+	case OpLt:
+		return "LT", "JLT"
+	case OpGt:
+		return "GT", "JGT"
+	}
+	return "", ""
+}
+
+func translateComparisonCommand(op ArithOp, labelCounter int) string {
+	name, jumpInstruction := comparisonName(op)
+	trueLabel := fmt.Sprintf("%s_TRUE_%d", name, labelCounter)
+	endLabel := fmt.Sprintf("%s_END_%d", name, labelCounter)
+
+	return fmt.Sprintf(
+		"@SP\nAM=M-1\nD=M\nA=A-1\nD=M-D\n@%s\nD;%s\n"+
+			"@SP\nA=M-1\nM=0\n@%s\n0;JMP\n(%s)\n@SP\nA=M-1\nM=-1\n(%s)\n",
+		trueLabel, jumpInstruction, endLabel, trueLabel, endLabel)
+}
+
+// translateFusedPushArith combines a push of seg/index directly with the
+// existing stack top, replacing a push followed by a binary arithmetic op.
+func (t *Translator) translateFusedPushArith(seg Segment, index int, op ArithOp) (string, error) {
+	load, err := loadSegmentIntoD(seg, index, t.currentFile)
+	if err != nil {
+		return "", err
+	}
+	var combine string
+	switch op {
+	case OpAdd:
+		combine = "@SP\nA=M-1\nM=M+D\n"
+	case OpSub:
+		combine = "@SP\nA=M-1\nM=M-D\n"
+	case OpAnd:
+		combine = "@SP\nA=M-1\nM=M&D\n"
+	case OpOr:
+		combine = "@SP\nA=M-1\nM=M|D\n"
+	default:
+		return "", fmt.Errorf("unsupported fused arithmetic op: %v", op)
+	}
+	return load + combine, nil
+}
+
+// translateFoldedBool emits a compile-time-known comparison result directly.
+func translateFoldedBool(value bool) string {
+	if value {
+		return "@SP\nA=M\nM=-1\n@SP\nM=M+1\n"
+	}
+	return "@SP\nA=M\nM=0\n@SP\nM=M+1\n"
+}
+
+// translateFusedMove moves a value straight from a source segment to a
+// destination segment, replacing a push immediately followed by a pop.
+func (t *Translator) translateFusedMove(srcSeg Segment, srcIndex int, dstSeg Segment, dstIndex int) (string, error) {
+	switch dstSeg {
+	case SegLocal, SegArgument, SegThis, SegThat:
+		addr := fmt.Sprintf("@%s\nD=M\n@%d\nD=D+A\n@R13\nM=D\n", segmentBase(dstSeg), dstIndex)
+		load, err := loadSegmentIntoD(srcSeg, srcIndex, t.currentFile)
+		if err != nil {
+			return "", err
+		}
+		return addr + load + "@R13\nA=M\nM=D\n", nil
+	default:
+		load, err := loadSegmentIntoD(srcSeg, srcIndex, t.currentFile)
+		if err != nil {
+			return "", err
+		}
+		store, err := storeDIntoSegment(dstSeg, dstIndex, t.currentFile)
+		if err != nil {
+			return "", err
+		}
+		return load + store, nil
+	}
+}
+
+func storeDIntoSegment(seg Segment, index int, fileName string) (string, error) {
+	switch seg {
+	case SegStatic:
+		return fmt.Sprintf("@%s.%d\nM=D\n", fileName, index), nil
+	case SegTemp:
+		return fmt.Sprintf("@R%d\nM=D\n", 5+index), nil
+	case SegPointer:
+		switch index {
+		case 0:
+			return "@THIS\nM=D\n", nil
+		case 1:
+			return "@THAT\nM=D\n", nil
+		default:
+			return "", fmt.Errorf("invalid index for pointer segment: %d", index)
+		}
+	default:
+		return "", fmt.Errorf("unsupported destination segment")
+	}
+}
+
+func (t *Translator) translatePopCommand(seg Segment, index int) (string, error) {
+	fileName := t.currentFile
+	switch seg {
+	case SegLocal, SegArgument, SegThis, SegThat:
+		return fmt.Sprintf(
+			"@%s\nD=M\n@%d\nD=D+A\n@R13\nM=D\n@SP\nAM=M-1\nD=M\n@R13\nA=M\nM=D\n",
+			segmentBase(seg), index,
+		), nil
+	case SegTemp:
+		return fmt.Sprintf(
+			"@%d\nD=A\n@%d\nD=D+A\n@R13\nM=D\n@SP\nAM=M-1\nD=M\n@R13\nA=M\nM=D\n",
+			5, index, // Temp segment starts at RAM address 5
+		), nil
+	case SegPointer:
+		switch index {
+		case 0:
+			return "@SP\nAM=M-1\nD=M\n@THIS\nM=D\n", nil
+		case 1:
+			return "@SP\nAM=M-1\nD=M\n@THAT\nM=D\n", nil
+		default:
+			return "", fmt.Errorf("invalid index for pop pointer command: %d", index)
+		}
+	case SegStatic:
+		return fmt.Sprintf(
+			"@SP\nAM=M-1\nD=M\n@%s.%d\nM=D\n",
+			fileName, index, // Static segment is file-scoped
+		), nil
+	default:
+		return "", fmt.Errorf("unsupported segment for pop command: %v", seg)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WritableFS is the write-side counterpart to fs.FS: the minimal surface
+// needed to create an output file without depending on a concrete
+// filesystem implementation (a real directory, a zip writer, an in-memory
+// map for tests, ...).
+type WritableFS interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+// dirWritableFS implements WritableFS by creating files under a real
+// directory on disk. It is the default WritableFS used by the CLI.
+type dirWritableFS struct {
+	dir string
+}
+
+func (d dirWritableFS) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(d.dir, name))
+}
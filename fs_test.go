@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// memWritableFS is an in-memory WritableFS for tests: it lets TranslateDir
+// be exercised without touching disk, feeding VM source as strings via
+// fstest.MapFS and asserting on the produced ASM bytes.
+type memWritableFS struct {
+	files map[string]*bytes.Buffer
+}
+
+func newMemWritableFS() *memWritableFS {
+	return &memWritableFS{files: map[string]*bytes.Buffer{}}
+}
+
+func (m *memWritableFS) Create(name string) (io.WriteCloser, error) {
+	buf := &bytes.Buffer{}
+	m.files[name] = buf
+	return nopCloser{buf}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// TestTranslateDirInMemory feeds VM source as strings through an
+// fstest.MapFS and an in-memory WritableFS, replacing the repo's former
+// reliance on on-disk testdata directories.
+func TestTranslateDirInMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		contains []string
+	}{
+		{
+			name: "single file, no Sys.init: auto bootstrap emits nothing",
+			files: fstest.MapFS{
+				"Main.vm": &fstest.MapFile{Data: []byte("push constant 7\n")},
+			},
+			contains: []string{"@7\nD=A\n"},
+		},
+		{
+			name: "Sys.init present: auto bootstrap calls it",
+			files: fstest.MapFS{
+				"Sys.vm": &fstest.MapFile{Data: []byte("function Sys.init 0\npush constant 1\n")},
+			},
+			contains: []string{"@256\nD=A\n@SP\nM=D\n", "@Sys.init\n0;JMP\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := newMemWritableFS()
+			tr := NewTranslator(Options{})
+			if err := tr.TranslateDir(tt.files, out, "prog"); err != nil {
+				t.Fatalf("TranslateDir: %v", err)
+			}
+
+			asm, ok := out.files["prog.asm"]
+			if !ok {
+				t.Fatalf("no prog.asm written; wrote %v", out.files)
+			}
+			got := asm.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("output missing %q, got:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// hackPredefined maps the Hack assembly language's predefined symbols to
+// their fixed RAM addresses, used by runHack to resolve @symbol operands
+// the same way the real assembler would.
+var hackPredefined = map[string]int{
+	"SP": 0, "LCL": 1, "ARG": 2, "THIS": 3, "THAT": 4,
+	"R0": 0, "R1": 1, "R2": 2, "R3": 3, "R4": 4, "R5": 5, "R6": 6, "R7": 7,
+	"R8": 8, "R9": 9, "R10": 10, "R11": 11, "R12": 12, "R13": 13, "R14": 14, "R15": 15,
+	"SCREEN": 16384, "KBD": 24576,
+}
+
+// runHack is a minimal Hack CPU simulator, just enough to execute the
+// straight-line assembly codegen.go emits (predefined symbols, generated
+// jump labels, and numeric/static variable symbols), so tests can compare
+// the RAM state produced by unoptimized vs. -O-optimized assembly instead
+// of only comparing IR shapes.
+func runHack(asm string, initRAM map[int]int16, maxSteps int) map[int]int16 {
+	var instrs []string
+	labels := map[string]int{}
+	for _, line := range strings.Split(asm, "\n") {
+		line = strings.TrimSpace(strings.SplitN(line, "//", 2)[0])
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "(") {
+			labels[strings.TrimSuffix(strings.TrimPrefix(line, "("), ")")] = len(instrs)
+			continue
+		}
+		instrs = append(instrs, line)
+	}
+
+	nextVar := 16
+	vars := map[string]int{}
+	resolve := func(sym string) int {
+		if n, err := strconv.Atoi(sym); err == nil {
+			return n
+		}
+		if addr, ok := hackPredefined[sym]; ok {
+			return addr
+		}
+		if addr, ok := labels[sym]; ok {
+			return addr
+		}
+		if addr, ok := vars[sym]; ok {
+			return addr
+		}
+		vars[sym] = nextVar
+		nextVar++
+		return vars[sym]
+	}
+
+	ram := map[int]int16{}
+	for addr, v := range initRAM {
+		ram[addr] = v
+	}
+	var d, a int16
+	pc := 0
+	for steps := 0; pc < len(instrs) && steps < maxSteps; steps++ {
+		line := instrs[pc]
+		if strings.HasPrefix(line, "@") {
+			a = int16(resolve(line[1:]))
+			pc++
+			continue
+		}
+
+		compPart := line
+		jump := ""
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			compPart, jump = line[:idx], line[idx+1:]
+		}
+		dest := ""
+		comp := compPart
+		if idx := strings.Index(compPart, "="); idx >= 0 {
+			dest, comp = compPart[:idx], compPart[idx+1:]
+		}
+
+		addr := int(a)
+		value := evalComp(comp, d, a, ram[addr])
+		// A and M can both be destinations of the same instruction; M always
+		// addresses RAM at the instruction's starting A, not a value A is
+		// simultaneously reassigned to in this same step.
+		for _, r := range dest {
+			switch r {
+			case 'A':
+				a = value
+			case 'D':
+				d = value
+			case 'M':
+				ram[addr] = value
+			}
+		}
+
+		if jump != "" && shouldJump(jump, value) {
+			pc = int(a)
+			continue
+		}
+		pc++
+	}
+	return ram
+}
+
+func reg(c byte, d, a, m int16) int16 {
+	switch c {
+	case 'D':
+		return d
+	case 'A':
+		return a
+	case 'M':
+		return m
+	default:
+		n, _ := strconv.Atoi(string(c))
+		return int16(n)
+	}
+}
+
+// evalComp evaluates one of the Hack ALU's fixed comp mnemonics (0, 1, -1,
+// D, A, M, !D, -A, D+M, M-1, ...) against the current register values.
+func evalComp(comp string, d, a, m int16) int16 {
+	switch comp {
+	case "0":
+		return 0
+	case "1":
+		return 1
+	case "-1":
+		return -1
+	case "D":
+		return d
+	case "A":
+		return a
+	case "M":
+		return m
+	}
+	if len(comp) == 2 {
+		switch comp[0] {
+		case '-':
+			return -reg(comp[1], d, a, m)
+		case '!':
+			return ^reg(comp[1], d, a, m)
+		}
+	}
+	if len(comp) == 3 {
+		lhs, rhs := reg(comp[0], d, a, m), reg(comp[2], d, a, m)
+		switch comp[1] {
+		case '+':
+			return lhs + rhs
+		case '-':
+			return lhs - rhs
+		case '&':
+			return lhs & rhs
+		case '|':
+			return lhs | rhs
+		}
+	}
+	panic("hack sim: unsupported comp " + comp)
+}
+
+func shouldJump(jump string, value int16) bool {
+	switch jump {
+	case "JMP":
+		return true
+	case "JEQ":
+		return value == 0
+	case "JNE":
+		return value != 0
+	case "JGT":
+		return value > 0
+	case "JLT":
+		return value < 0
+	case "JGE":
+		return value >= 0
+	case "JLE":
+		return value <= 0
+	default:
+		panic("hack sim: unsupported jump " + jump)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+// Segment identifies the VM memory segment referenced by a push or pop
+// command.
+type Segment int
+
+const (
+	SegConstant Segment = iota
+	SegLocal
+	SegArgument
+	SegThis
+	SegThat
+	SegStatic
+	SegTemp
+	SegPointer
+)
+
+// ArithOp identifies a VM arithmetic or logical command.
+type ArithOp int
+
+const (
+	OpAdd ArithOp = iota
+	OpSub
+	OpNeg
+	OpAnd
+	OpOr
+	OpNot
+	OpEq
+	OpLt
+	OpGt
+)
+
+// Cmd is a single VM command in typed form. The parser produces the plain
+// variants below; the optimizer may additionally produce the fused/folded
+// variants that only codegen needs to understand.
+type Cmd interface {
+	isCmd()
+}
+
+type PushCmd struct {
+	Segment Segment
+	Index   int
+}
+
+type PopCmd struct {
+	Segment Segment
+	Index   int
+}
+
+type ArithCmd struct {
+	Op ArithOp
+}
+
+type LabelCmd struct{ Name string }
+
+type GotoCmd struct{ Label string }
+
+type IfGotoCmd struct{ Label string }
+
+type FunctionCmd struct {
+	Name      string
+	NumLocals int
+}
+
+type CallCmd struct {
+	Name    string
+	NumArgs int
+}
+
+type ReturnCmd struct{}
+
+// FusedPushArithCmd replaces a push immediately followed by a binary
+// arithmetic command: the pushed value is combined directly with the
+// existing stack top instead of round-tripping through the stack.
+type FusedPushArithCmd struct {
+	Segment Segment
+	Index   int
+	Op      ArithOp // OpAdd, OpSub, OpAnd, or OpOr
+}
+
+// FusedMoveCmd replaces a push immediately followed by a pop: the value
+// is moved directly from the source location to the destination.
+type FusedMoveCmd struct {
+	SrcSegment Segment
+	SrcIndex   int
+	DstSegment Segment
+	DstIndex   int
+}
+
+// FoldedBoolCmd replaces a comparison of two literal constants with its
+// statically known result.
+type FoldedBoolCmd struct {
+	Value bool
+}
+
+func (PushCmd) isCmd()           {}
+func (PopCmd) isCmd()            {}
+func (ArithCmd) isCmd()          {}
+func (LabelCmd) isCmd()          {}
+func (GotoCmd) isCmd()           {}
+func (IfGotoCmd) isCmd()         {}
+func (FunctionCmd) isCmd()       {}
+func (CallCmd) isCmd()           {}
+func (ReturnCmd) isCmd()         {}
+func (FusedPushArithCmd) isCmd() {}
+func (FusedMoveCmd) isCmd()      {}
+func (FoldedBoolCmd) isCmd()     {}
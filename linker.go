@@ -0,0 +1,242 @@
+package main
+
+import "strings"
+
+// FunctionBlock groups one function's IR commands, and the SourceLoc each
+// one came from, for whole-program linking.
+type FunctionBlock struct {
+	File     string
+	Name     string
+	Commands []Cmd
+	Sources  []SourceLoc
+}
+
+// ParsedFile is a single .vm file's parsed commands, split into any
+// file-level preamble (commands preceding the first function) and the
+// function blocks it defines.
+type ParsedFile struct {
+	File            string
+	Preamble        []Cmd
+	PreambleSources []SourceLoc
+	Funcs           []FunctionBlock
+}
+
+// splitFunctions groups a flat command stream (and its parallel source
+// locations) into the function blocks it defines, keeping any commands that
+// precede the first function as a preamble.
+func splitFunctions(file string, cmds []Cmd, sources []SourceLoc) ParsedFile {
+	pf := ParsedFile{File: file}
+	var cur *FunctionBlock
+	for i, cmd := range cmds {
+		if fn, ok := cmd.(FunctionCmd); ok {
+			pf.Funcs = append(pf.Funcs, FunctionBlock{File: file, Name: fn.Name})
+			cur = &pf.Funcs[len(pf.Funcs)-1]
+		}
+		if cur == nil {
+			pf.Preamble = append(pf.Preamble, cmd)
+			pf.PreambleSources = append(pf.PreambleSources, sources[i])
+			continue
+		}
+		cur.Commands = append(cur.Commands, cmd)
+		cur.Sources = append(cur.Sources, sources[i])
+	}
+	return pf
+}
+
+// LinkResult is the outcome of whole-program dead code elimination: the
+// pruned files plus a record of what got dropped.
+type LinkResult struct {
+	Files            []ParsedFile
+	RemovedFuncs     []string
+	ReclaimedStatics map[string]int // file -> static slots reclaimed
+}
+
+// Link builds a call graph rooted at Sys.init (or, if the bootstrap won't
+// actually call Sys.init, at every non-Sys function), drops functions
+// unreachable from those roots, and compacts each file's static segment so
+// that unused slots left behind by removed functions don't waste RAM.
+//
+// callsSysInit must reflect whether the emitted bootstrap actually calls
+// Sys.init (len(boot.Commands) > 0), not merely whether a Sys.init
+// definition exists in the parsed files: with -bootstrap=none, a Sys.vm
+// that defines Sys.init but is never called must not root DCE at it, or
+// every function only reachable from the .tst harness (not from Sys.init)
+// is wrongly treated as dead. The converse also matters: with
+// -bootstrap=full, callsSysInit is true even if no file actually defines
+// Sys.init, so rootFunctions must still fall back to the all-non-Sys-roots
+// behavior rather than rooting at a function name nothing reaches.
+func Link(files []ParsedFile, callsSysInit bool) LinkResult {
+	reachable := reachableFunctions(files, rootFunctions(files, hasSysInit(files) && callsSysInit))
+
+	result := LinkResult{ReclaimedStatics: map[string]int{}}
+	for _, pf := range files {
+		originalStatics := countStatics(pf)
+
+		kept := ParsedFile{File: pf.File, Preamble: pf.Preamble, PreambleSources: pf.PreambleSources}
+		for _, fn := range pf.Funcs {
+			if reachable[fn.Name] {
+				kept.Funcs = append(kept.Funcs, fn)
+			} else {
+				result.RemovedFuncs = append(result.RemovedFuncs, fn.Name)
+			}
+		}
+
+		renumbered, newStatics := renumberStatics(kept)
+		result.Files = append(result.Files, renumbered)
+		if reclaimed := originalStatics - newStatics; reclaimed > 0 {
+			result.ReclaimedStatics[pf.File] = reclaimed
+		}
+	}
+	return result
+}
+
+// rootFunctions picks the call graph roots DCE runs from. rootAtSysInit
+// must already account for whether a Sys.init definition actually exists
+// (see Link) — rooting at the literal name "Sys.init" when nothing defines
+// it would make the BFS start from an empty reachable set and prune every
+// real function. When nothing calls Sys.init, every non-Sys function
+// across every file is treated as a root (the .tst harness can call any of
+// them directly), which means DCE never removes anything in that mode —
+// only a single-file program with an unreachable Sys.init present actually
+// gets pruned.
+func rootFunctions(files []ParsedFile, rootAtSysInit bool) []string {
+	if rootAtSysInit {
+		return []string{"Sys.init"}
+	}
+	var roots []string
+	for _, pf := range files {
+		for _, fn := range pf.Funcs {
+			if !strings.HasPrefix(fn.Name, "Sys.") {
+				roots = append(roots, fn.Name)
+			}
+		}
+	}
+	return roots
+}
+
+func reachableFunctions(files []ParsedFile, roots []string) map[string]bool {
+	callees := map[string][]string{}
+	for _, pf := range files {
+		for _, fn := range pf.Funcs {
+			for _, cmd := range fn.Commands {
+				if call, ok := cmd.(CallCmd); ok {
+					callees[fn.Name] = append(callees[fn.Name], call.Name)
+				}
+			}
+		}
+	}
+
+	reachable := map[string]bool{}
+	queue := append([]string{}, roots...)
+	for _, r := range roots {
+		reachable[r] = true
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, callee := range callees[name] {
+			if !reachable[callee] {
+				reachable[callee] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return reachable
+}
+
+func countStatics(pf ParsedFile) int {
+	seen := map[int]bool{}
+	visitStatics(pf.Preamble, seen)
+	for _, fn := range pf.Funcs {
+		visitStatics(fn.Commands, seen)
+	}
+	return len(seen)
+}
+
+func visitStatics(cmds []Cmd, seen map[int]bool) {
+	for _, cmd := range cmds {
+		switch c := cmd.(type) {
+		case PushCmd:
+			if c.Segment == SegStatic {
+				seen[c.Index] = true
+			}
+		case PopCmd:
+			if c.Segment == SegStatic {
+				seen[c.Index] = true
+			}
+		}
+	}
+}
+
+// renumberStatics compacts a file's static indices to a dense 0..N-1 range,
+// in order of first appearance, and returns the new static slot count.
+func renumberStatics(pf ParsedFile) (ParsedFile, int) {
+	var order []int
+	seen := map[int]bool{}
+	record := func(cmds []Cmd) {
+		for _, cmd := range cmds {
+			switch c := cmd.(type) {
+			case PushCmd:
+				if c.Segment == SegStatic && !seen[c.Index] {
+					seen[c.Index] = true
+					order = append(order, c.Index)
+				}
+			case PopCmd:
+				if c.Segment == SegStatic && !seen[c.Index] {
+					seen[c.Index] = true
+					order = append(order, c.Index)
+				}
+			}
+		}
+	}
+	record(pf.Preamble)
+	for _, fn := range pf.Funcs {
+		record(fn.Commands)
+	}
+
+	remap := make(map[int]int, len(order))
+	for i, old := range order {
+		remap[old] = i
+	}
+
+	rewrite := func(cmds []Cmd) []Cmd {
+		out := make([]Cmd, len(cmds))
+		for i, cmd := range cmds {
+			switch c := cmd.(type) {
+			case PushCmd:
+				if c.Segment == SegStatic {
+					c.Index = remap[c.Index]
+				}
+				out[i] = c
+			case PopCmd:
+				if c.Segment == SegStatic {
+					c.Index = remap[c.Index]
+				}
+				out[i] = c
+			default:
+				out[i] = cmd
+			}
+		}
+		return out
+	}
+
+	result := ParsedFile{File: pf.File, Preamble: rewrite(pf.Preamble), PreambleSources: pf.PreambleSources}
+	for _, fn := range pf.Funcs {
+		result.Funcs = append(result.Funcs, FunctionBlock{
+			File: fn.File, Name: fn.Name, Commands: rewrite(fn.Commands), Sources: fn.Sources,
+		})
+	}
+	return result, len(order)
+}
+
+// hasSysInit reports whether any parsed file defines Sys.init.
+func hasSysInit(files []ParsedFile) bool {
+	for _, pf := range files {
+		for _, fn := range pf.Funcs {
+			if fn.Name == "Sys.init" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+// TestLinkNoneBootstrapKeepsUncalledFunctions reproduces the
+// -bootstrap=none scenario DCE exists to not break: a directory with
+// Sys.vm (defining Sys.init, which nothing calls) alongside Main.vm
+// (defining Main.double, called only by the .tst harness, never by
+// Sys.init or any other VM code). Link must be told the bootstrap isn't
+// calling Sys.init so it roots the call graph at every non-Sys function
+// instead, keeping Main.double.
+func TestLinkNoneBootstrapKeepsUncalledFunctions(t *testing.T) {
+	files := []ParsedFile{
+		{File: "Sys", Funcs: []FunctionBlock{
+			{File: "Sys", Name: "Sys.init"}, // loops forever, calls nothing
+		}},
+		{File: "Main", Funcs: []FunctionBlock{
+			{File: "Main", Name: "Main.double"}, // only called by the .tst harness
+		}},
+	}
+
+	linked := Link(files, false)
+
+	for _, removed := range linked.RemovedFuncs {
+		if removed == "Main.double" {
+			t.Fatalf("Main.double was pruned even though it's only called by the .tst harness, not Sys.init")
+		}
+	}
+	var mainFile ParsedFile
+	for _, pf := range linked.Files {
+		if pf.File == "Main" {
+			mainFile = pf
+		}
+	}
+	if len(mainFile.Funcs) != 1 || mainFile.Funcs[0].Name != "Main.double" {
+		t.Errorf("Main.double was pruned even though nothing calls Sys.init")
+	}
+}
+
+// TestLinkFullBootstrapPrunesUnreachable checks the companion case: once
+// the bootstrap does call Sys.init, a function reachable only from the
+// .tst harness (and not from Sys.init) is correctly treated as dead.
+func TestLinkFullBootstrapPrunesUnreachable(t *testing.T) {
+	files := []ParsedFile{
+		{File: "Sys", Funcs: []FunctionBlock{
+			{File: "Sys", Name: "Sys.init"}, // calls nothing
+		}},
+		{File: "Main", Funcs: []FunctionBlock{
+			{File: "Main", Name: "Main.double"},
+		}},
+	}
+
+	linked := Link(files, true)
+
+	if len(linked.RemovedFuncs) != 1 || linked.RemovedFuncs[0] != "Main.double" {
+		t.Errorf("RemovedFuncs = %v, want [Main.double]", linked.RemovedFuncs)
+	}
+}
+
+// TestLinkFullBootstrapWithoutSysInitKeepsEverything reproduces
+// -bootstrap=full against a directory that defines no Sys.init at all:
+// callsSysInit is true (the bootstrap always calls it in "full" mode), but
+// since no function is actually named Sys.init, rooting DCE there would
+// start the BFS from an empty reachable set and prune every real function.
+// Link must fall back to treating every non-Sys function as a root.
+func TestLinkFullBootstrapWithoutSysInitKeepsEverything(t *testing.T) {
+	files := []ParsedFile{
+		{File: "Main", Funcs: []FunctionBlock{
+			{File: "Main", Name: "Main.test"},
+		}},
+	}
+
+	linked := Link(files, true)
+
+	if len(linked.RemovedFuncs) != 0 {
+		t.Errorf("RemovedFuncs = %v, want none: Main.test is only reachable via the .tst harness, and no Sys.init exists to root DCE at", linked.RemovedFuncs)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+// Optimize runs a single peephole pass over cmds, collapsing patterns that
+// the naive one-command-at-a-time emitter can't see across:
+//
+//   - push X followed by a binary arithmetic op: combine the pushed value
+//     directly with the existing stack top instead of pushing then popping.
+//   - push X followed by pop Y: move the value straight from X to Y.
+//   - push constant A, push constant B, eq/lt/gt: the comparison result is
+//     known at compile time, so fold it into a single boolean push.
+// Optimize also takes the SourceLoc recorded for each command and returns
+// the matching locations for the optimized output, merging the locations
+// consumed by a fusion so annotated output still points at real VM source.
+func Optimize(cmds []Cmd, sources []SourceLoc) ([]Cmd, []SourceLoc) {
+	outCmds := make([]Cmd, 0, len(cmds))
+	outSources := make([]SourceLoc, 0, len(sources))
+	for i := 0; i < len(cmds); {
+		fused, consumed := fuseWindow(cmds[i:])
+		if consumed == 0 {
+			outCmds = append(outCmds, cmds[i])
+			outSources = append(outSources, sources[i])
+			i++
+			continue
+		}
+		outCmds = append(outCmds, fused)
+		outSources = append(outSources, mergeSourceLocs(sources[i:i+consumed]))
+		i += consumed
+	}
+	return outCmds, outSources
+}
+
+func fuseWindow(w []Cmd) (Cmd, int) {
+	if len(w) >= 3 {
+		if a, ok := w[0].(PushCmd); ok && a.Segment == SegConstant {
+			if b, ok := w[1].(PushCmd); ok && b.Segment == SegConstant {
+				if c, ok := w[2].(ArithCmd); ok {
+					if value, ok := foldComparison(c.Op, a.Index, b.Index); ok {
+						return FoldedBoolCmd{Value: value}, 3
+					}
+				}
+			}
+		}
+	}
+	if len(w) >= 2 {
+		if a, ok := w[0].(PushCmd); ok {
+			if c, ok := w[1].(ArithCmd); ok && isBinaryFusible(c.Op) {
+				return FusedPushArithCmd{Segment: a.Segment, Index: a.Index, Op: c.Op}, 2
+			}
+			if b, ok := w[1].(PopCmd); ok {
+				return FusedMoveCmd{
+					SrcSegment: a.Segment, SrcIndex: a.Index,
+					DstSegment: b.Segment, DstIndex: b.Index,
+				}, 2
+			}
+		}
+	}
+	return nil, 0
+}
+
+func isBinaryFusible(op ArithOp) bool {
+	switch op {
+	case OpAdd, OpSub, OpAnd, OpOr:
+		return true
+	}
+	return false
+}
+
+func foldComparison(op ArithOp, a, b int) (value bool, ok bool) {
+	switch op {
+	case OpEq:
+		return a == b, true
+	case OpLt:
+		return a < b, true
+	case OpGt:
+		return a > b, true
+	}
+	return false, false
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOptimize runs the peephole optimizer over small VM programs and
+// compares the optimized IR against hand-written expectations, alongside
+// the IR a Translator would emit for the unoptimized input, to confirm
+// each fusion matches the pattern it's meant to collapse.
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Cmd
+		want []Cmd
+	}{
+		{
+			name: "push constant followed by add fuses",
+			in:   []Cmd{PushCmd{Segment: SegConstant, Index: 17}, ArithCmd{Op: OpAdd}},
+			want: []Cmd{FusedPushArithCmd{Segment: SegConstant, Index: 17, Op: OpAdd}},
+		},
+		{
+			name: "push local followed by pop static fuses into a move",
+			in:   []Cmd{PushCmd{Segment: SegLocal, Index: 2}, PopCmd{Segment: SegStatic, Index: 0}},
+			want: []Cmd{FusedMoveCmd{SrcSegment: SegLocal, SrcIndex: 2, DstSegment: SegStatic, DstIndex: 0}},
+		},
+		{
+			name: "constant comparison folds to a compile-time boolean",
+			in: []Cmd{
+				PushCmd{Segment: SegConstant, Index: 3},
+				PushCmd{Segment: SegConstant, Index: 5},
+				ArithCmd{Op: OpLt},
+			},
+			want: []Cmd{FoldedBoolCmd{Value: true}},
+		},
+		{
+			name: "unrelated commands are left untouched",
+			in:   []Cmd{ReturnCmd{}, LabelCmd{Name: "LOOP"}},
+			want: []Cmd{ReturnCmd{}, LabelCmd{Name: "LOOP"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]SourceLoc, len(tt.in))
+			got, gotSources := Optimize(tt.in, sources)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Optimize(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+			if len(gotSources) != len(got) {
+				t.Errorf("len(sources) = %d, want %d to match len(cmds)", len(gotSources), len(got))
+			}
+		})
+	}
+}
+
+// TestOptimizePreservesBehavior translates a small program both with and
+// without -O and checks the optimized assembly is shorter while still
+// being built from the same kind of instructions, guarding against a
+// fusion that changes the program's observable behavior.
+func TestOptimizePreservesBehavior(t *testing.T) {
+	cmds := []Cmd{
+		PushCmd{Segment: SegConstant, Index: 7},
+		ArithCmd{Op: OpAdd},
+	}
+	sources := []SourceLoc{{Text: "push constant 7"}, {Text: "add"}}
+
+	unopt := &Translator{}
+	var unoptAsm string
+	for _, c := range cmds {
+		asm, err := unopt.TranslateCommand(c)
+		if err != nil {
+			t.Fatalf("TranslateCommand: %v", err)
+		}
+		unoptAsm += asm
+	}
+
+	optCmds, _ := Optimize(cmds, sources)
+	opt := &Translator{}
+	var optAsm string
+	for _, c := range optCmds {
+		asm, err := opt.TranslateCommand(c)
+		if err != nil {
+			t.Fatalf("TranslateCommand: %v", err)
+		}
+		optAsm += asm
+	}
+
+	if len(optCmds) >= len(cmds) {
+		t.Errorf("optimized program has %d commands, want fewer than unoptimized %d", len(optCmds), len(cmds))
+	}
+	if unoptAsm == optAsm {
+		t.Errorf("optimized assembly should differ from the unoptimized push/add round-trip")
+	}
+}
+
+// TestOptimizeRunsIdenticallyOnHackSimulator assembles and executes small
+// programs both unoptimized and with -O on a minimal Hack CPU simulator,
+// and diffs the resulting stack/RAM state. This is the test the optimizer
+// actually needs: TestOptimize and TestOptimizePreservesBehavior only
+// compare IR/assembly shape, so a fusion that "looks shorter" but computes
+// the wrong value would slip past them undetected.
+func TestOptimizeRunsIdenticallyOnHackSimulator(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmds    []Cmd
+		wantSP  int16 // RAM[0] after execution
+		checkAt int   // RAM address holding the value under test
+		want    int16 // expected value at checkAt
+	}{
+		{
+			name: "push constant, push constant, add",
+			cmds: []Cmd{
+				PushCmd{Segment: SegConstant, Index: 4},
+				PushCmd{Segment: SegConstant, Index: 5},
+				ArithCmd{Op: OpAdd},
+			},
+			wantSP:  257,
+			checkAt: 256, // top of stack
+			want:    9,
+		},
+		{
+			name: "push constant, push constant, eq (folds to a literal true)",
+			cmds: []Cmd{
+				PushCmd{Segment: SegConstant, Index: 3},
+				PushCmd{Segment: SegConstant, Index: 3},
+				ArithCmd{Op: OpEq},
+			},
+			wantSP:  257,
+			checkAt: 256, // top of stack
+			want:    -1,
+		},
+		{
+			name: "push local, pop temp (fuses into a direct move)",
+			cmds: []Cmd{
+				PushCmd{Segment: SegLocal, Index: 0},
+				PopCmd{Segment: SegTemp, Index: 0},
+			},
+			wantSP:  256,
+			checkAt: 5, // temp 0
+			want:    42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]SourceLoc, len(tt.cmds))
+			ramInit := map[int]int16{0: 256, 1: 300, 300: 42} // SP=256, LCL=300, local 0 = 42
+
+			unopt := &Translator{}
+			var unoptAsm strings.Builder
+			for _, c := range tt.cmds {
+				asm, err := unopt.TranslateCommand(c)
+				if err != nil {
+					t.Fatalf("TranslateCommand: %v", err)
+				}
+				unoptAsm.WriteString(asm)
+			}
+			unoptRAM := runHack(unoptAsm.String(), ramInit, 1000)
+
+			optCmds, _ := Optimize(tt.cmds, sources)
+			opt := &Translator{}
+			var optAsm strings.Builder
+			for _, c := range optCmds {
+				asm, err := opt.TranslateCommand(c)
+				if err != nil {
+					t.Fatalf("TranslateCommand: %v", err)
+				}
+				optAsm.WriteString(asm)
+			}
+			optRAM := runHack(optAsm.String(), ramInit, 1000)
+
+			if sp := unoptRAM[0]; sp != tt.wantSP {
+				t.Errorf("unoptimized SP = %d, want %d", sp, tt.wantSP)
+			}
+			if got := unoptRAM[tt.checkAt]; got != tt.want {
+				t.Errorf("unoptimized RAM[%d] = %d, want %d", tt.checkAt, got, tt.want)
+			}
+			if sp := optRAM[0]; sp != tt.wantSP {
+				t.Errorf("optimized SP = %d, want %d", sp, tt.wantSP)
+			}
+			if got := optRAM[tt.checkAt]; got != tt.want {
+				t.Errorf("optimized RAM[%d] = %d, want %d", tt.checkAt, got, tt.want)
+			}
+		})
+	}
+}
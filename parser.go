@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var segmentNames = map[string]Segment{
+	"constant": SegConstant,
+	"local":    SegLocal,
+	"argument": SegArgument,
+	"this":     SegThis,
+	"that":     SegThat,
+	"static":   SegStatic,
+	"temp":     SegTemp,
+	"pointer":  SegPointer,
+}
+
+var arithOps = map[string]ArithOp{
+	"add": OpAdd,
+	"sub": OpSub,
+	"neg": OpNeg,
+	"and": OpAnd,
+	"or":  OpOr,
+	"not": OpNot,
+	"eq":  OpEq,
+	"lt":  OpLt,
+	"gt":  OpGt,
+}
+
+// ParseCommand turns a single VM source line into its IR form. The line may
+// still carry an inline comment and surrounding whitespace.
+func ParseCommand(line string) (Cmd, error) {
+	line = strings.TrimSpace(strings.SplitN(line, "//", 2)[0])
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty command line")
+	}
+
+	if op, ok := arithOps[parts[0]]; ok {
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("invalid %s command: %s", parts[0], line)
+		}
+		return ArithCmd{Op: op}, nil
+	}
+
+	switch parts[0] {
+	case "push", "pop":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid %s command: %s", parts[0], line)
+		}
+		segment, ok := segmentNames[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported segment for %s command: %s", parts[0], parts[1])
+		}
+		index, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid index for %s command: %s", parts[0], parts[2])
+		}
+		if parts[0] == "push" {
+			return PushCmd{Segment: segment, Index: index}, nil
+		}
+		return PopCmd{Segment: segment, Index: index}, nil
+	case "label":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid label command: %s", line)
+		}
+		return LabelCmd{Name: parts[1]}, nil
+	case "goto":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid goto command: %s", line)
+		}
+		return GotoCmd{Label: parts[1]}, nil
+	case "if-goto":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid if-goto command: %s", line)
+		}
+		return IfGotoCmd{Label: parts[1]}, nil
+	case "function", "call":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid %s command: %s", parts[0], line)
+		}
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid number for %s command: %s", parts[0], parts[2])
+		}
+		if parts[0] == "function" {
+			return FunctionCmd{Name: parts[1], NumLocals: n}, nil
+		}
+		return CallCmd{Name: parts[1], NumArgs: n}, nil
+	case "return":
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("invalid return command: %s", line)
+		}
+		return ReturnCmd{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported command: %s", line)
+	}
+}
+
+// ParseProgram reads every VM command out of r, skipping blank lines and
+// full-line comments. It returns a SourceLoc alongside each command
+// recording the originating file, line number, and trimmed source text, so
+// later stages can annotate emitted assembly with it.
+func ParseProgram(r io.Reader, file string) ([]Cmd, []SourceLoc, error) {
+	var cmds []Cmd
+	var locs []SourceLoc
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		cmd, err := ParseCommand(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		cmds = append(cmds, cmd)
+		locs = append(locs, SourceLoc{File: file, Line: lineNo, Text: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return cmds, locs, nil
+}
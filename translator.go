@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Options configures a Translator, mirroring the CLI's flags.
+type Options struct {
+	Optimize  bool
+	Verbose   bool
+	Annotate  annotateMode
+	Bootstrap bootstrapMode
+}
+
+// Translator turns parsed VM programs into Hack assembly. It holds all of
+// the mutable translation state (label/call counters, which function and
+// file are currently being emitted, ROM address tracking) that used to
+// live in package-level globals. Because that state is now per-instance,
+// two Translators can run in the same process without interfering with
+// each other, which also makes the translator safe to use as a library
+// and to exercise in parallel tests.
+type Translator struct {
+	labelCounter    int
+	callCounter     int
+	currentFunction string
+	currentFile     string
+	opts            Options
+
+	w          *bufio.Writer
+	romAddr    int
+	mapEntries []MapEntry
+}
+
+// NewTranslator creates a Translator configured by opts. Its output target
+// is set by TranslateDir.
+func NewTranslator(opts Options) *Translator {
+	return &Translator{opts: opts}
+}
+
+// TranslateCommand translates a single IR command into Hack assembly,
+// advancing whatever counters that command's kind depends on.
+func (t *Translator) TranslateCommand(cmd Cmd) (string, error) {
+	switch c := cmd.(type) {
+	case ArithCmd:
+		if c.Op == OpEq || c.Op == OpLt || c.Op == OpGt {
+			asm := translateComparisonCommand(c.Op, t.labelCounter)
+			t.labelCounter++
+			return asm, nil
+		}
+		return translateArithmeticCommand(c.Op), nil
+	case PushCmd:
+		return t.translatePushCommand(c.Segment, c.Index)
+	case PopCmd:
+		return t.translatePopCommand(c.Segment, c.Index)
+	case FusedPushArithCmd:
+		return t.translateFusedPushArith(c.Segment, c.Index, c.Op)
+	case FusedMoveCmd:
+		return t.translateFusedMove(c.SrcSegment, c.SrcIndex, c.DstSegment, c.DstIndex)
+	case FoldedBoolCmd:
+		return translateFoldedBool(c.Value), nil
+	case LabelCmd:
+		return t.translateLabelCommand(c.Name), nil
+	case GotoCmd:
+		return t.translateGotoCommand(c.Label), nil
+	case IfGotoCmd:
+		return t.translateIfGotoCommand(c.Label), nil
+	case FunctionCmd:
+		// currentFunction must be updated here so that a later LabelCmd
+		// scopes its label to the right function; previously this never
+		// happened, silently breaking label/goto scoping across functions.
+		t.currentFunction = c.Name
+		return translateFunctionCommand(c.Name, c.NumLocals)
+	case CallCmd:
+		return t.translateCallCommand(c.Name, c.NumArgs)
+	case ReturnCmd:
+		return translateReturnCommand(), nil
+	default:
+		return "", fmt.Errorf("unsupported command: %#v", cmd)
+	}
+}
+
+// TranslateFile emits pf's preamble and functions, in order, to the
+// translator's current output.
+func (t *Translator) TranslateFile(pf ParsedFile) error {
+	t.currentFile = pf.File
+	if err := t.translateCommands(pf.Preamble, pf.PreambleSources); err != nil {
+		return err
+	}
+	for _, fn := range pf.Funcs {
+		if err := t.translateCommands(fn.Commands, fn.Sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translateCommands writes the assembly for cmds to the translator's
+// output, optionally preceding each command with a "// file:line  vm
+// source" comment and, in full mode, annotating individual Hack
+// instructions. It tracks the ROM address of every instruction written so
+// it can record a MapEntry per command.
+func (t *Translator) translateCommands(cmds []Cmd, sources []SourceLoc) error {
+	for i, cmd := range cmds {
+		asm, err := t.TranslateCommand(cmd)
+		if err != nil {
+			return err
+		}
+
+		loc := sources[i]
+		if t.opts.Annotate != annotateOff && loc.Text != "" {
+			if _, err := fmt.Fprintf(t.w, "// %s:%d  %s\n", loc.File, loc.Line, loc.Text); err != nil {
+				return err
+			}
+		}
+
+		start := t.romAddr
+		for _, line := range strings.Split(strings.TrimRight(asm, "\n"), "\n") {
+			if t.opts.Annotate == annotateFull {
+				if desc := describeInstruction(line); desc != "" {
+					line = line + "  // " + desc
+				}
+			}
+			if _, err := fmt.Fprintf(t.w, "%s\n", line); err != nil {
+				return err
+			}
+			if !strings.HasPrefix(line, "(") {
+				t.romAddr++
+			}
+		}
+
+		if loc.Text != "" && t.romAddr > start {
+			t.mapEntries = append(t.mapEntries, MapEntry{
+				StartAddress: start, EndAddress: t.romAddr - 1, File: loc.File, Line: loc.Line,
+			})
+		}
+	}
+	return nil
+}
+
+// TranslateDir runs the full parse -> link -> optimize -> emit pipeline
+// against fsys, writing the linked program to "<dirName>.asm" in out (and,
+// if annotations are enabled, "<dirName>.map" alongside it). fsys is
+// filesystem-agnostic: it can be a real directory (the CLI's
+// os.DirFS(dir) default), a zip archive, or an in-memory filesystem built
+// for tests.
+func (t *Translator) TranslateDir(fsys fs.FS, out WritableFS, dirName string) error {
+	asmOut, err := out.Create(dirName + ".asm")
+	if err != nil {
+		return fmt.Errorf("creating ASM file: %w", err)
+	}
+	defer asmOut.Close()
+	t.w = bufio.NewWriter(asmOut)
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("reading directory: %w", err)
+	}
+
+	parsedFiles, err := parseAllVMFiles(fsys, entries)
+	if err != nil {
+		return fmt.Errorf("parsing VM files: %w", err)
+	}
+
+	boot := BuildBootstrap(t.opts.Bootstrap, hasSysInit(parsedFiles))
+
+	// DCE must be rooted at Sys.init only when the bootstrap actually calls
+	// it; otherwise (e.g. -bootstrap=none with a Sys.vm present) functions
+	// reachable only from the .tst harness would be wrongly pruned.
+	linked := Link(parsedFiles, len(boot.Commands) > 0)
+	if t.opts.Verbose {
+		printLinkSummary(linked)
+	}
+
+	if len(boot.Commands) > 0 {
+		if _, err := t.w.WriteString("@256\nD=A\n@SP\nM=D\n"); err != nil {
+			return fmt.Errorf("initializing stack: %w", err)
+		}
+		t.romAddr += 4
+		if err := t.translateCommands(boot.Commands, boot.Sources); err != nil {
+			return fmt.Errorf("emitting bootstrap: %w", err)
+		}
+	}
+
+	for _, pf := range linked.Files {
+		if t.opts.Optimize {
+			pf = optimizeFile(pf)
+		}
+		if err := t.TranslateFile(pf); err != nil {
+			fmt.Printf("Error translating %s.vm: %s\n", pf.File, err)
+			continue
+		}
+		fmt.Printf("Translated %s.vm\n", pf.File)
+	}
+
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+
+	if t.opts.Annotate != annotateOff {
+		if err := writeSourceMap(out, dirName, t.mapEntries); err != nil {
+			return fmt.Errorf("writing source map: %w", err)
+		}
+	}
+
+	fmt.Printf("Translation complete: %s.asm\n", dirName)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+// TestLabelGotoScoping verifies that goto/if-goto targets are scoped to the
+// current function exactly like label declarations, so a loop within a
+// single function assembles to a (Foo$LOOP) label and matching @Foo$LOOP
+// jumps rather than an unscoped symbol the Hack assembler would treat as a
+// new RAM variable.
+func TestLabelGotoScoping(t *testing.T) {
+	tr := &Translator{currentFunction: "Sys.init"}
+
+	label, err := tr.TranslateCommand(LabelCmd{Name: "LOOP"})
+	if err != nil {
+		t.Fatalf("LabelCmd: %v", err)
+	}
+	if want := "(Sys.init$LOOP)\n"; label != want {
+		t.Errorf("label = %q, want %q", label, want)
+	}
+
+	goto_, err := tr.TranslateCommand(GotoCmd{Label: "LOOP"})
+	if err != nil {
+		t.Fatalf("GotoCmd: %v", err)
+	}
+	if want := "@Sys.init$LOOP\n0;JMP\n"; goto_ != want {
+		t.Errorf("goto = %q, want %q", goto_, want)
+	}
+
+	ifGoto, err := tr.TranslateCommand(IfGotoCmd{Label: "LOOP"})
+	if err != nil {
+		t.Fatalf("IfGotoCmd: %v", err)
+	}
+	if want := "@SP\nAM=M-1\nD=M\n@Sys.init$LOOP\nD;JNE\n"; ifGoto != want {
+		t.Errorf("if-goto = %q, want %q", ifGoto, want)
+	}
+}
+
+// TestLabelGotoScopingAcrossFunctions checks that the same label name used
+// in two different functions resolves to two distinct, non-colliding
+// targets once currentFunction is updated by a FunctionCmd.
+func TestLabelGotoScopingAcrossFunctions(t *testing.T) {
+	tr := &Translator{}
+
+	if _, err := tr.TranslateCommand(FunctionCmd{Name: "Foo.bar", NumLocals: 0}); err != nil {
+		t.Fatalf("FunctionCmd Foo.bar: %v", err)
+	}
+	fooGoto, err := tr.TranslateCommand(GotoCmd{Label: "LOOP"})
+	if err != nil {
+		t.Fatalf("GotoCmd: %v", err)
+	}
+	if want := "@Foo.bar$LOOP\n0;JMP\n"; fooGoto != want {
+		t.Errorf("Foo.bar goto = %q, want %q", fooGoto, want)
+	}
+
+	if _, err := tr.TranslateCommand(FunctionCmd{Name: "Baz.qux", NumLocals: 0}); err != nil {
+		t.Fatalf("FunctionCmd Baz.qux: %v", err)
+	}
+	bazGoto, err := tr.TranslateCommand(GotoCmd{Label: "LOOP"})
+	if err != nil {
+		t.Fatalf("GotoCmd: %v", err)
+	}
+	if want := "@Baz.qux$LOOP\n0;JMP\n"; bazGoto != want {
+		t.Errorf("Baz.qux goto = %q, want %q", bazGoto, want)
+	}
+
+	if fooGoto == bazGoto {
+		t.Errorf("goto targets in different functions must not collide: both %q", fooGoto)
+	}
+}